@@ -0,0 +1,88 @@
+// Package bsonutil provides helpers for converting between JSON and BSON
+// representations of documents.
+package bsonutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ConvertJSONValueToBSON reads the next complete JSON value from dec and
+// returns its BSON equivalent. Objects are decoded as bson.D rather than
+// map[string]interface{}, so that field order - which encoding/json's map
+// decoding would otherwise discard - is preserved.
+func ConvertJSONValueToBSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return convertToken(dec, tok)
+}
+
+// convertToken converts a single already-read JSON token, recursing into
+// dec for the remainder of composite values.
+func convertToken(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		if num, ok := tok.(json.Number); ok {
+			return convertNumber(num)
+		}
+		// a scalar: string, bool, or nil
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		doc := bson.D{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := keyTok.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected object key, got %v", keyTok)
+			}
+			value, err := ConvertJSONValueToBSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, bson.DocElem{Name: key, Value: value})
+		}
+		if _, err := dec.Token(); err != nil { // consume closing '}'
+			return nil, err
+		}
+		return doc, nil
+	case '[':
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := ConvertJSONValueToBSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		if _, err := dec.Token(); err != nil { // consume closing ']'
+			return nil, err
+		}
+		return arr, nil
+	}
+
+	return nil, fmt.Errorf("unexpected JSON delimiter: %v", delim)
+}
+
+// convertNumber converts a json.Number token into an int64, falling back
+// to a float64 when the number doesn't fit in (or isn't) an integer, so
+// that numeric JSON values are stored as real BSON numbers rather than as
+// the raw decimal string json.Number wraps.
+func convertNumber(num json.Number) (interface{}, error) {
+	if i, err := num.Int64(); err == nil {
+		return i, nil
+	}
+	f, err := num.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON number %q: %v", num, err)
+	}
+	return f, nil
+}
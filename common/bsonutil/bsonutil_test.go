@@ -0,0 +1,77 @@
+package bsonutil
+
+import (
+	"encoding/json"
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertJSONValueToBSON(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  interface{}
+	}{
+		{name: "scalar string", input: `"hi"`, want: "hi"},
+		{name: "scalar bool", input: "true", want: true},
+		{name: "scalar null", input: "null", want: nil},
+		{name: "scalar integer", input: "42", want: int64(42)},
+		{name: "scalar float", input: "3.14", want: 3.14},
+		{name: "integer too large for int64", input: "18446744073709551615", want: float64(18446744073709551615)},
+		{
+			name:  "object preserves field order",
+			input: `{"b": 1, "a": 2}`,
+			want: bson.D{
+				{Name: "b", Value: int64(1)},
+				{Name: "a", Value: int64(2)},
+			},
+		},
+		{
+			name:  "nested object",
+			input: `{"a": {"b": 1}}`,
+			want: bson.D{
+				{Name: "a", Value: bson.D{
+					{Name: "b", Value: int64(1)},
+				}},
+			},
+		},
+		{
+			name:  "array of scalars",
+			input: `[1, 2, 3]`,
+			want:  []interface{}{int64(1), int64(2), int64(3)},
+		},
+		{
+			name:  "array of objects",
+			input: `[{"a": 1}, {"a": 2}]`,
+			want: []interface{}{
+				bson.D{{Name: "a", Value: int64(1)}},
+				bson.D{{Name: "a", Value: int64(2)}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dec := json.NewDecoder(strings.NewReader(c.input))
+			dec.UseNumber()
+			got, err := ConvertJSONValueToBSON(dec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestConvertJSONValueToBSONTruncatedObject(t *testing.T) {
+	// an object with no closing '}' is malformed input;
+	// ConvertJSONValueToBSON should surface an error rather than panic
+	dec := json.NewDecoder(strings.NewReader(`{`))
+	if _, err := ConvertJSONValueToBSON(dec); err == nil {
+		t.Fatal("expected an error for a truncated object, got nil")
+	}
+}
@@ -0,0 +1,67 @@
+// Package options defines the command-line options shared by the
+// mongoimport tool.
+package options
+
+// InputOptions defines the set of options to use in converting input data
+// to BSON documents.
+type InputOptions struct {
+	// Fields is an option to directly specify comma-separated fields to
+	// import to CSV
+	Fields *string `long:"fields" short:"f" description:"comma separated list of field names, e.g. -f name,age"`
+
+	// FieldFile is a filename that refers to a list of fields to import,
+	// 1 per line
+	FieldFile *string `long:"fieldFile" description:"file with field names - 1 per line"`
+
+	// Type selects the type of data to import
+	Type string `long:"type" description:"input format to import: json, csv, or tsv (defaults to 'json')" default:"json"`
+
+	// File is the input file to import from, or stdin if unspecified
+	File string `long:"file" description:"file to import from; if not specified, stdin is used"`
+
+	// HeaderLine, if set, uses the first line in the input source as the
+	// field list (CSV and TSV only)
+	HeaderLine bool `long:"headerline" description:"use first line in input source as the field list (CSV and TSV only)"`
+
+	// TSVDelimiter is the field delimiter used when parsing TSV input
+	TSVDelimiter string `long:"tsvDelim" description:"delimiter to use when parsing TSV input (defaults to a tab character)" default:"\t"`
+
+	// CSVDelimiter is the field delimiter used when parsing CSV input
+	CSVDelimiter string `long:"csvDelim" description:"delimiter to use when parsing CSV input (defaults to a comma)" default:","`
+
+	// Quote is the character used to quote fields in CSV input
+	Quote string `long:"quote" description:"character that is used to quote fields in CSV input (defaults to a double quote mark)" default:"\""`
+
+	// QuoteEscape is the character used to escape a quote character
+	// inside an already quoted CSV value
+	QuoteEscape string `long:"quoteEscape" description:"character used to escape a quote character inside an already quoted CSV value (defaults to a double quote mark)" default:"\""`
+
+	// ColumnsHaveTypes, if set, indicates that the field list (from
+	// --fields, --fieldFile, or a header line) carries a type
+	// annotation for each column, e.g. "age.int32()"
+	ColumnsHaveTypes bool `long:"columnsHaveTypes" description:"indicates that the field list (from --fields, --fieldFile, or a header line) specifies types; if not specified, all fields are imported as strings or auto-detected numeric/boolean values"`
+
+	// ParseGrace controls what happens when a token fails to parse
+	// according to the type specified for its column
+	ParseGrace string `long:"parseGrace" description:"controls behavior when type coercion fails for --columnsHaveTypes ('autoCast', 'skipField', 'skipRow', or 'stop')" default:"stop"`
+
+	// ReadBlockSize is the size, in bytes, of each block read from CSV
+	// and TSV input sources for concurrent, block-parallel decoding
+	ReadBlockSize int `long:"readBlockSize" description:"size in bytes of each block read from CSV/TSV input for parallel decoding" default:"1048576"`
+
+	// NumDecodingWorkers is the number of concurrent goroutines used to
+	// decode input into BSON documents. A value <= 0 means the number
+	// of CPUs available should be used.
+	NumDecodingWorkers int `long:"numDecodingWorkers" description:"number of concurrent goroutines to use for decoding input (defaults to the number of CPUs)" default:"0"`
+
+	// JSONArray, if set, treats the entire input source as a single
+	// top-level JSON array whose elements are streamed as documents,
+	// rather than as newline-delimited JSON
+	JSONArray bool `long:"jsonArray" description:"treat input source as a single JSON array (JSON input only)"`
+}
+
+// Name returns the name of this set of options, for use with option
+// grouping.
+func (*InputOptions) Name() string {
+	return "input"
+}
@@ -0,0 +1,237 @@
+package mongoimport
+
+import (
+	"fmt"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ConvertibleDoc is an interface that allows conversion of its underlying
+// data to a bson.D document
+type ConvertibleDoc interface {
+	Convert() (bson.D, error)
+}
+
+// InputReader is an interface that specifies how an input source should be
+// converted to BSON
+type InputReader interface {
+	// StreamDocument takes in two channels: it sends processed documents on
+	// the readDocChan channel and if any error is encountered, the error is
+	// sent on the errChan channel. It keeps reading from the underlying
+	// input source until it hits EOF or an error. If ordered is true, it
+	// streams the documents in the order in which they are read
+	StreamDocument(ordered bool, readDocChan chan bson.D, errChan chan error)
+
+	// ReadAndValidateHeader reads the header from the underlying input
+	// source, if applicable, and returns an error if the fields it
+	// specifies are invalid
+	ReadAndValidateHeader() error
+
+	// sizeTracker is embedded so callers can check how many bytes have
+	// been read from the underlying input source so far
+	sizeTracker
+}
+
+// sizeTracker tracks the number of bytes read from a given input source
+type sizeTracker interface {
+	Size() int64
+}
+
+// sizeTrackingReader wraps an io.Reader, counting the number of bytes
+// read through it so far
+type sizeTrackingReader struct {
+	io.Reader
+	bytesRead int64
+}
+
+func (str *sizeTrackingReader) Size() int64 {
+	return atomic.LoadInt64(&str.bytesRead)
+}
+
+func (str *sizeTrackingReader) Read(p []byte) (n int, err error) {
+	n, err = str.Reader.Read(p)
+	atomic.AddInt64(&str.bytesRead, int64(n))
+	return
+}
+
+// validateReaderFields is a helper to validate that a column list -
+// whether passed in directly via --fields/--fieldFile or read from a
+// CSV/TSV header line - contains no empty or duplicate field names, and
+// that any dotted or array-subscript field names are internally
+// consistent (see validateNestedFieldPaths)
+func validateReaderFields(columns []ColumnSpec) error {
+	seen := map[string]bool{}
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column.Name == "" {
+			return fmt.Errorf("header contains an empty field name")
+		}
+		if seen[column.Name] {
+			return fmt.Errorf("header contains duplicate field: %v", column.Name)
+		}
+		seen[column.Name] = true
+		names = append(names, column.Name)
+	}
+	return validateNestedFieldPaths(names)
+}
+
+// errSkipRow is returned by tokensToBSON when a column's ParseGrace is
+// PGSkipRow and one of its tokens fails to parse; streamDocuments drops
+// the row without treating the error as fatal
+var errSkipRow = fmt.Errorf("row skipped due to parseGrace policy")
+
+// tokensToBSON reads in a slice of tokens - along with the ColumnSpecs
+// they correspond to - and returns the equivalent BSON document, applying
+// each column's parser and ParseGrace policy
+func tokensToBSON(columns []ColumnSpec, tokens []string, numProcessed uint64) (bson.D, error) {
+	document := bson.D{}
+	for index, token := range tokens {
+		if index < len(columns) {
+			column := columns[index]
+			value, err := column.Parser.Parse(token)
+			if err != nil {
+				switch column.ParseGrace {
+				case PGAutoCast:
+					value = token
+				case PGSkipField:
+					continue
+				case PGSkipRow:
+					return nil, errSkipRow
+				default: // PGStop
+					return nil, fmt.Errorf("entry #%v: %v", numProcessed, err)
+				}
+			}
+			if err := setNestedField(&document, column.Name, value); err != nil {
+				return nil, fmt.Errorf("entry #%v: %v", numProcessed, err)
+			}
+		} else {
+			document = append(document, bson.DocElem{
+				Name:  "field" + strconv.Itoa(index),
+				Value: getParsedValue(token),
+			})
+		}
+	}
+	return document, nil
+}
+
+// getParsedValue returns the appropriate concrete type for the given
+// token, heuristically trying float64 and bool before falling back to
+// the raw string
+func getParsedValue(token string) interface{} {
+	if value, err := strconv.ParseFloat(token, 64); err == nil {
+		return value
+	}
+	if value, err := strconv.ParseBool(token); err == nil {
+		return value
+	}
+	return token
+}
+
+// streamDocuments concurrently converts the ConvertibleDocs read off
+// readChan into BSON documents using numDecoders goroutines, and sends the
+// results on outputChan. If ordered is true, documents are sent on
+// outputChan in the same order they were received on readChan
+func streamDocuments(ordered bool, numDecoders int, readChan chan ConvertibleDoc, outputChan chan bson.D) (retErr error) {
+	if numDecoders <= 0 {
+		numDecoders = 1
+	}
+
+	if !ordered {
+		var wg sync.WaitGroup
+		var errMutex sync.Mutex
+		wg.Add(numDecoders)
+		for i := 0; i < numDecoders; i++ {
+			go func() {
+				defer wg.Done()
+				for doc := range readChan {
+					bsonDoc, err := doc.Convert()
+					if err != nil {
+						if err != errSkipRow {
+							errMutex.Lock()
+							if retErr == nil {
+								retErr = err
+							}
+							errMutex.Unlock()
+						}
+						continue
+					}
+					outputChan <- bsonDoc
+				}
+			}()
+		}
+		wg.Wait()
+		return
+	}
+
+	// ordered: documents must be emitted in the same order they were
+	// read, so convert them in parallel but replay the results in order
+	indexedChan := make(chan indexedBSON, numDecoders)
+	var wg sync.WaitGroup
+	wg.Add(numDecoders)
+	var convertErrMutex sync.Mutex
+	idx := uint64(0)
+	var idxMutex sync.Mutex
+	for i := 0; i < numDecoders; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				// receive and number each document under the same lock,
+				// so that indices follow the order of readChan
+				idxMutex.Lock()
+				doc, ok := <-readChan
+				myIdx := idx
+				idx++
+				idxMutex.Unlock()
+				if !ok {
+					return
+				}
+				bsonDoc, err := doc.Convert()
+				skip := err != nil
+				if err != nil && err != errSkipRow {
+					convertErrMutex.Lock()
+					if retErr == nil {
+						retErr = err
+					}
+					convertErrMutex.Unlock()
+				}
+				indexedChan <- indexedBSON{index: myIdx, doc: bsonDoc, skip: skip}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(indexedChan)
+	}()
+
+	pending := map[uint64]indexedBSON{}
+	var next uint64
+	for item := range indexedChan {
+		pending[item.index] = item
+		for {
+			item, ok := pending[next]
+			if !ok {
+				break
+			}
+			if !item.skip {
+				outputChan <- item.doc
+			}
+			delete(pending, next)
+			next++
+		}
+	}
+	return
+}
+
+// indexedBSON pairs a converted BSON document with its original position
+// in the input stream, so ordered streaming can replay results in order.
+// skip is set when the document should be dropped rather than emitted
+// (e.g. a row discarded by the --parseGrace=skipRow policy or a fatal
+// conversion error).
+type indexedBSON struct {
+	index uint64
+	doc   bson.D
+	skip  bool
+}
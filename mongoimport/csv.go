@@ -1,7 +1,7 @@
 package mongoimport
 
 import (
-	"fmt"
+	"bytes"
 	"github.com/mongodb/mongo-tools/mongoimport/csv"
 	"gopkg.in/mgo.v2/bson"
 	"io"
@@ -11,17 +11,27 @@ import (
 // CSV input source
 type CSVInputReader struct {
 
-	// fields is a list of field names in the BSON documents to be imported
-	fields []string
+	// columns holds the name and parser for each BSON field to be
+	// imported, in column order
+	columns []ColumnSpec
 
-	// csvReader is the underlying reader used to read data in from the CSV or CSV file
-	csvReader *csv.Reader
+	// columnsHaveTypes indicates that the header line (or --fields
+	// list) carries a type annotation for each column, e.g. "age.int32()"
+	columnsHaveTypes bool
+
+	// parseGrace controls what happens when a token fails to parse
+	// according to its column's type
+	parseGrace ParseGrace
 
-	// csvRecord stores each line of input we read from the underlying reader
-	csvRecord []string
+	// csvReader is the underlying reader used to read the header line;
+	// StreamDocument continues reading from its buffered input, via
+	// csvReader.Underlying(), so that no bytes are dropped between the
+	// header read and the start of block-based decoding
+	csvReader *csv.Reader
 
-	// numProcessed tracks the number of CSV records processed by the underlying reader
-	numProcessed uint64
+	// readBlockSize is the size, in bytes, of each block StreamDocument
+	// reads from the underlying input source for parallel decoding
+	readBlockSize int
 
 	// numDecoders is the number of concurrent goroutines to use for decoding
 	numDecoders int
@@ -32,72 +42,114 @@ type CSVInputReader struct {
 
 // CSVConvertibleDoc implements the ConvertibleDoc interface for CSV input
 type CSVConvertibleDoc struct {
-	fields, data []string
-	index        uint64
+	columns []ColumnSpec
+	data    []string
+	index   uint64
 }
 
-// NewCSVInputReader returns a CSVInputReader configured to read input from the
-// given io.Reader, extracting the specified fields only.
-func NewCSVInputReader(fields []string, in io.Reader, numDecoders int) *CSVInputReader {
+// NewCSVInputReader returns a CSVInputReader configured to read input from
+// the given io.Reader, extracting the specified fields only. The delim,
+// quote, and escape runes configure the field delimiter, the quote
+// character, and the character used to escape a quote inside an
+// already-quoted value, respectively. If columnsHaveTypes is true, fields
+// (whether passed in directly or read from a header line) are expected to
+// carry a type annotation, and parseGrace governs how parse failures for
+// those types are handled.
+func NewCSVInputReader(fields []string, in io.Reader, numDecoders int, delim, quote, escape rune, columnsHaveTypes bool, parseGrace ParseGrace) *CSVInputReader {
 	szCount := &sizeTrackingReader{in, 0}
 	csvReader := csv.NewReader(szCount)
 	// allow variable number of fields in document
 	csvReader.FieldsPerRecord = -1
 	csvReader.TrimLeadingSpace = true
+	csvReader.Comma = delim
+	csvReader.Quote = quote
+	csvReader.Escape = escape
 	return &CSVInputReader{
-		fields:       fields,
-		csvReader:    csvReader,
-		numProcessed: uint64(0),
-		numDecoders:  numDecoders,
-		sizeTracker:  szCount,
+		columns:          newAutoColumnSpecs(fields, parseGrace),
+		columnsHaveTypes: columnsHaveTypes,
+		parseGrace:       parseGrace,
+		csvReader:        csvReader,
+		readBlockSize:    defaultReadBlockSize,
+		numDecoders:      numDecoders,
+		sizeTracker:      szCount,
 	}
 }
 
-// ReadAndValidateHeader sets the import fields for a CSV importer
+// ReadAndValidateHeader sets the import fields for a CSV importer. If
+// columnsHaveTypes is set, each header token is expected to carry a type
+// annotation, e.g. "age.int32()".
 func (csvInputReader *CSVInputReader) ReadAndValidateHeader() (err error) {
 	fields, err := csvInputReader.csvReader.Read()
 	if err != nil {
 		return err
 	}
-	csvInputReader.fields = fields
-	return validateReaderFields(csvInputReader.fields)
+	columns := make([]ColumnSpec, len(fields))
+	for i, field := range fields {
+		if csvInputReader.columnsHaveTypes {
+			columns[i], err = parseTypedHeader(field, csvInputReader.parseGrace)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		columns[i] = ColumnSpec{Name: field, Parser: autoParser{}, ParseGrace: csvInputReader.parseGrace}
+	}
+	csvInputReader.columns = columns
+	return validateReaderFields(csvInputReader.columns)
 }
 
 // StreamDocument takes in two channels: it sends processed documents on the
-// readDocChan channel and if any error is encountered, the error is sent on the
-// errChan channel. It keeps reading from the underlying input source until it
-// hits EOF or an error. If ordered is true, it streams the documents in which
-// the documents are read
+// readDocChan channel and if any error is encountered, the error is sent on
+// the errChan channel. A splitter goroutine reads fixed-size blocks from
+// the underlying input source, aligning each block on a row boundary that
+// accounts for quoted embedded newlines, and hands the blocks to a pool of
+// numDecoders goroutines that each decode their block into documents
+// independently. If ordered is true, documents are streamed in the order
+// the rows were read, even though blocks may finish decoding out of order.
 func (csvInputReader *CSVInputReader) StreamDocument(ordered bool, readDocChan chan bson.D, errChan chan error) {
-	csvRecordChan := make(chan ConvertibleDoc, csvInputReader.numDecoders)
-	go func() {
-		var err error
+	comma, quote, escape := csvInputReader.csvReader.Comma, csvInputReader.csvReader.Quote, csvInputReader.csvReader.Escape
+
+	blockChan := make(chan rawBlock, csvInputReader.numDecoders)
+	splitErrChan := make(chan error, 1)
+	go splitBlocks(csvInputReader.csvReader.Underlying(), csvInputReader.readBlockSize, csvLineBoundaryFinder(quote, escape), blockChan, splitErrChan)
+
+	decodeBlock := func(data []byte) ([][]string, error) {
+		blockReader := csv.NewReader(bytes.NewReader(data))
+		blockReader.FieldsPerRecord = -1
+		blockReader.TrimLeadingSpace = true
+		blockReader.Comma = comma
+		blockReader.Quote = quote
+		blockReader.Escape = escape
+
+		rows := rowsPool.Get().([][]string)[:0]
 		for {
-			csvInputReader.csvRecord, err = csvInputReader.csvReader.Read()
+			record, err := blockReader.Read()
 			if err != nil {
-				if err != io.EOF {
-					csvInputReader.numProcessed++
-					errChan <- fmt.Errorf("read error on entry #%v: %v", csvInputReader.numProcessed, err)
+				if err == io.EOF {
+					return rows, nil
 				}
-				close(csvRecordChan)
-				return
+				return rows, err
 			}
-			csvRecordChan <- CSVConvertibleDoc{
-				fields: csvInputReader.fields,
-				data:   csvInputReader.csvRecord,
-				index:  csvInputReader.numProcessed,
-			}
-			csvInputReader.numProcessed++
+			rows = append(rows, record)
 		}
-	}()
-	errChan <- streamDocuments(ordered, csvInputReader.numDecoders, csvRecordChan, readDocChan)
+	}
+
+	err := decodeBlocksAndStream(ordered, csvInputReader.numDecoders, blockChan, decodeBlock, csvInputReader.columns, readDocChan)
+	select {
+	case splitErr := <-splitErrChan:
+		if err == nil {
+			err = splitErr
+		}
+	default:
+	}
+	errChan <- err
 }
 
 // This is required to satisfy the ConvertibleDoc interface for CSV input. It
 // does CSV-specific processing to convert the CSVConvertibleDoc to a bson.D
 func (csvConvertibleDoc CSVConvertibleDoc) Convert() (bson.D, error) {
 	return tokensToBSON(
-		csvConvertibleDoc.fields,
+		csvConvertibleDoc.columns,
 		csvConvertibleDoc.data,
 		csvConvertibleDoc.index,
 	)
@@ -0,0 +1,240 @@
+// Package csv implements a CSV reader modeled on encoding/csv, extended to
+// support a configurable field delimiter, quote character, and
+// quote-escape character, since the standard library's reader hardcodes
+// both the comma and the doubled-quote escaping convention.
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ParseError is returned for parsing errors. Line numbers are 1-indexed
+// and increment on each record read, not on each physical input line, so
+// that errors can be attributed to a particular row even when quoted
+// fields span multiple physical lines.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// ErrQuote is returned when a quoted field is not properly terminated.
+var ErrQuote = fmt.Errorf("extraneous or missing quote in quoted-field")
+
+// Reader reads records from a CSV-encoded file, with a configurable
+// field delimiter, quote character, and quote-escape character.
+//
+// Comma, Quote, and Escape default to ',', '"', and '"' respectively when
+// a Reader is constructed with NewReader, matching the conventional CSV
+// dialect. Setting Escape to a different rune from Quote (e.g. '\\')
+// switches the quoted-field escaping convention from doubled-quotes
+// (`""`) to a backslash-style escape (`\"`).
+type Reader struct {
+	// Comma is the field delimiter
+	Comma rune
+
+	// Quote is the character used to quote fields containing the
+	// delimiter, the quote character itself, or embedded newlines
+	Quote rune
+
+	// Escape is the character that, when it immediately precedes a Quote
+	// inside a quoted field, causes that Quote to be treated as a
+	// literal character rather than the end of the field
+	Escape rune
+
+	// FieldsPerRecord, if positive, requires each record to have the
+	// given number of fields. If 0, it is set to the number of fields in
+	// the first record read, so that all subsequent records must have
+	// the same field count. Any negative value disables the check.
+	FieldsPerRecord int
+
+	// TrimLeadingSpace, if true, causes leading white space in a field
+	// to be ignored, even if the field delimiter is white space
+	TrimLeadingSpace bool
+
+	r    *bufio.Reader
+	line int
+}
+
+// NewReader returns a new Reader that reads from r, using the default
+// CSV dialect (comma-delimited, double-quote quoted, doubled-quote
+// escaped).
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		Comma:  ',',
+		Quote:  '"',
+		Escape: '"',
+		r:      bufio.NewReader(r),
+	}
+}
+
+// Underlying returns the bufio.Reader backing r. Callers that need to
+// keep reading raw bytes from the same stream after using r (for
+// instance, to read a header record before switching to a block-based
+// reader) should continue reading from this bufio.Reader rather than the
+// original io.Reader, since r may have buffered bytes past what it has
+// returned so far.
+func (r *Reader) Underlying() *bufio.Reader {
+	return r.r
+}
+
+// Read reads one record (a slice of fields) from r.
+func (r *Reader) Read() (record []string, err error) {
+	raw, err := r.readRecordBytes()
+	if err != nil {
+		return nil, err
+	}
+	r.line++
+
+	record, err = r.parseRecord(raw)
+	if err != nil {
+		return record, &ParseError{Line: r.line, Err: err}
+	}
+
+	if r.FieldsPerRecord > 0 {
+		if len(record) != r.FieldsPerRecord {
+			return record, &ParseError{Line: r.line, Err: fmt.Errorf("wrong number of fields in record")}
+		}
+	} else if r.FieldsPerRecord == 0 {
+		r.FieldsPerRecord = len(record)
+	}
+
+	return record, nil
+}
+
+// readRecordBytes reads the raw bytes making up the next record,
+// continuing past embedded newlines that occur inside a quoted field.
+func (r *Reader) readRecordBytes() ([]byte, error) {
+	var buf bytes.Buffer
+	inQuotes := false
+	sawAnyInput := false
+
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) > 0 {
+			sawAnyInput = true
+			buf.Write(line)
+		}
+		if err != nil {
+			if err == io.EOF && sawAnyInput {
+				break
+			}
+			return nil, err
+		}
+
+		// determine whether we're still inside an open quote by
+		// scanning the whole buffer so far; this is simpler than
+		// tracking escape state incrementally across reads, and
+		// records are expected to be of reasonable size
+		inQuotes = r.unterminatedQuote(buf.Bytes())
+		if !inQuotes {
+			break
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// unterminatedQuote reports whether data contains an odd, unterminated
+// run of quote characters, taking the configured Escape character into
+// account.
+func (r *Reader) unterminatedQuote(data []byte) bool {
+	open := false
+	runes := []rune(string(data))
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case open && runes[i] == r.Escape && r.Escape != r.Quote && i+1 < len(runes) && runes[i+1] == r.Quote:
+			i++
+		case open && runes[i] == r.Quote && r.Escape == r.Quote && i+1 < len(runes) && runes[i+1] == r.Quote:
+			i++
+		case runes[i] == r.Quote:
+			open = !open
+		}
+	}
+	return open
+}
+
+// parseRecord splits raw record bytes into fields, honoring Comma, Quote,
+// and Escape.
+func (r *Reader) parseRecord(raw []byte) ([]string, error) {
+	line := trimTrailingNewline(string(raw))
+	runes := []rune(line)
+
+	var fields []string
+	var field bytes.Buffer
+	inQuotes := false
+	fieldHadQuotes := false
+
+	i := 0
+	// skip leading whitespace on a field if requested
+	skipLeadingSpace := func() {
+		if !r.TrimLeadingSpace {
+			return
+		}
+		for i < len(runes) && (runes[i] == ' ' || runes[i] == '\t') && runes[i] != r.Comma {
+			i++
+		}
+	}
+	skipLeadingSpace()
+
+	for i < len(runes) {
+		ch := runes[i]
+		switch {
+		case inQuotes:
+			switch {
+			case ch == r.Escape && r.Escape != r.Quote && i+1 < len(runes) && runes[i+1] == r.Quote:
+				field.WriteRune(r.Quote)
+				i += 2
+				continue
+			case ch == r.Quote && r.Escape == r.Quote && i+1 < len(runes) && runes[i+1] == r.Quote:
+				field.WriteRune(r.Quote)
+				i += 2
+				continue
+			case ch == r.Quote:
+				inQuotes = false
+				i++
+			default:
+				field.WriteRune(ch)
+				i++
+			}
+		case ch == r.Quote && field.Len() == 0 && !fieldHadQuotes:
+			inQuotes = true
+			fieldHadQuotes = true
+			i++
+		case ch == r.Comma:
+			fields = append(fields, field.String())
+			field.Reset()
+			fieldHadQuotes = false
+			i++
+			skipLeadingSpace()
+		default:
+			field.WriteRune(ch)
+			i++
+		}
+	}
+	if inQuotes {
+		return nil, ErrQuote
+	}
+	fields = append(fields, field.String())
+
+	return fields, nil
+}
+
+func trimTrailingNewline(s string) string {
+	s = trimSuffix(s, "\n")
+	s = trimSuffix(s, "\r")
+	return s
+}
+
+func trimSuffix(s, suffix string) string {
+	if len(s) >= len(suffix) && s[len(s)-len(suffix):] == suffix {
+		return s[:len(s)-len(suffix)]
+	}
+	return s
+}
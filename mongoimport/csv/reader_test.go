@@ -0,0 +1,94 @@
+package csv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReaderDefaultDialect(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		records [][]string
+	}{
+		{
+			name:    "simple fields",
+			input:   "a,b,c\n1,2,3\n",
+			records: [][]string{{"a", "b", "c"}, {"1", "2", "3"}},
+		},
+		{
+			name:    "quoted field with embedded comma",
+			input:   `a,"b,c",d` + "\n",
+			records: [][]string{{"a", "b,c", "d"}},
+		},
+		{
+			name:    "quoted field with embedded newline",
+			input:   "a,\"b\nc\",d\n",
+			records: [][]string{{"a", "b\nc", "d"}},
+		},
+		{
+			name:    "doubled-quote escape",
+			input:   `a,"say ""hi""",b` + "\n",
+			records: [][]string{{"a", `say "hi"`, "b"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewReader(strings.NewReader(c.input))
+			var got [][]string
+			for {
+				record, err := r.Read()
+				if err != nil {
+					break
+				}
+				got = append(got, record)
+			}
+			if !reflect.DeepEqual(got, c.records) {
+				t.Fatalf("got %#v, want %#v", got, c.records)
+			}
+		})
+	}
+}
+
+func TestReaderConfigurableDialect(t *testing.T) {
+	r := NewReader(strings.NewReader("a|'b\\'c'|d\n"))
+	r.Comma = '|'
+	r.Quote = '\''
+	r.Escape = '\\'
+
+	record, err := r.Read()
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	want := []string{"a", "b'c", "d"}
+	if !reflect.DeepEqual(record, want) {
+		t.Fatalf("got %#v, want %#v", record, want)
+	}
+}
+
+func TestReaderUnterminatedQuote(t *testing.T) {
+	r := NewReader(strings.NewReader(`a,"b,c` + "\n"))
+	_, err := r.Read()
+	if err == nil {
+		t.Fatal("expected an error for an unterminated quote, got nil")
+	}
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected a *ParseError, got %T: %v", err, err)
+	}
+	if perr.Err != ErrQuote {
+		t.Fatalf("expected ErrQuote, got %v", perr.Err)
+	}
+}
+
+func TestReaderFieldsPerRecordMismatch(t *testing.T) {
+	r := NewReader(strings.NewReader("a,b,c\n1,2\n"))
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("unexpected error on first record: %v", err)
+	}
+	if _, err := r.Read(); err == nil {
+		t.Fatal("expected an error for a record with the wrong number of fields, got nil")
+	}
+}
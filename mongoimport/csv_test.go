@@ -0,0 +1,101 @@
+package mongoimport
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// streamAll drains an InputReader to completion, returning the documents
+// it streamed and any error it reported. StreamDocument signals
+// completion by sending on errChan rather than closing readDocChan, so
+// docs and the final error must be collected from the same select loop:
+// every document send happens-before the error send within StreamDocument,
+// so once errChan is ready, no further document is pending.
+func streamAll(r InputReader, ordered bool) ([]bson.D, error) {
+	docChan := make(chan bson.D)
+	errChan := make(chan error, 1)
+	go r.StreamDocument(ordered, docChan, errChan)
+
+	var docs []bson.D
+	for {
+		select {
+		case doc := <-docChan:
+			docs = append(docs, doc)
+		case err := <-errChan:
+			return docs, err
+		}
+	}
+}
+
+func newTestCSVReader(t *testing.T, input string, numDecoders, readBlockSize int) *CSVInputReader {
+	t.Helper()
+	r := NewCSVInputReader([]string{"a", "b"}, strings.NewReader(input), numDecoders, ',', '"', '"', false, PGAutoCast)
+	r.readBlockSize = readBlockSize
+	return r
+}
+
+func TestCSVStreamDocumentMatchesAcrossDecoderCounts(t *testing.T) {
+	var rows []string
+	for i := 0; i < 300; i++ {
+		rows = append(rows, "x,y")
+	}
+	input := strings.Join(rows, "\n") + "\n"
+
+	// a tiny readBlockSize forces many small blocks, exercising the
+	// carry-forward path in splitBlocks, while numDecoders>1 forces
+	// blocks to be decoded out of order and reassembled
+	single, err := streamAll(newTestCSVReader(t, input, 1, 16), true)
+	if err != nil {
+		t.Fatalf("unexpected error with 1 decoder: %v", err)
+	}
+	multi, err := streamAll(newTestCSVReader(t, input, 8, 16), true)
+	if err != nil {
+		t.Fatalf("unexpected error with 8 decoders: %v", err)
+	}
+
+	if !reflect.DeepEqual(single, multi) {
+		t.Fatalf("ordered output with multiple decoders diverged from single-decoder output")
+	}
+	if len(single) != len(rows) {
+		t.Fatalf("got %d docs, want %d", len(single), len(rows))
+	}
+}
+
+func TestCSVStreamDocumentQuotedNewlineAcrossBlockBoundary(t *testing.T) {
+	// the embedded newline inside the quoted field falls right where a
+	// small readBlockSize would otherwise cut the block
+	input := "1,\"line one\nline two\"\n3,4\n"
+
+	docs, err := streamAll(newTestCSVReader(t, input, 2, 8), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []bson.D{
+		{{Name: "a", Value: 1.0}, {Name: "b", Value: "line one\nline two"}},
+		{{Name: "a", Value: 3.0}, {Name: "b", Value: 4.0}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Fatalf("got %#v, want %#v", docs, want)
+	}
+}
+
+func TestCSVStreamDocumentBlankLine(t *testing.T) {
+	input := "1,2\n\n3,4\n"
+
+	docs, err := streamAll(newTestCSVReader(t, input, 1, defaultReadBlockSize), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []bson.D{
+		{{Name: "a", Value: 1.0}, {Name: "b", Value: 2.0}},
+		{{Name: "a", Value: ""}},
+		{{Name: "a", Value: 3.0}, {Name: "b", Value: 4.0}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Fatalf("got %#v, want %#v", docs, want)
+	}
+}
@@ -0,0 +1,153 @@
+package mongoimport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/bsonutil"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+)
+
+// defaultMaxJSONElementSize caps how large a single JSON array element
+// may be, to guard against pathological input (e.g. a file with a
+// missing closing bracket) consuming unbounded memory
+const defaultMaxJSONElementSize = 64 * 1024 * 1024 // 64MB
+
+// JSONArrayInputReader is a struct that implements the InputReader
+// interface for a single top-level JSON array input source, e.g.
+// `[{...}, {...}, ...]`. Unlike CSVInputReader and TSVInputReader, it
+// takes no field list: each array element becomes a document whose
+// structure is taken directly from the JSON.
+type JSONArrayInputReader struct {
+	// decoder is the token-level JSON decoder used to stream array
+	// elements one at a time, without holding the whole array in memory
+	decoder *json.Decoder
+
+	// elementLimit bounds the number of bytes decoder may read while
+	// decoding a single array element, so that a pathologically large
+	// element is rejected as it is read rather than after it has
+	// already been buffered in full
+	elementLimit *limitedReader
+
+	// numProcessed tracks the number of JSON elements processed so far
+	numProcessed uint64
+
+	// numDecoders is the number of concurrent goroutines to use for decoding
+	numDecoders int
+
+	// embedded sizeTracker exposes the Size() method to check the number of bytes read so far
+	sizeTracker
+}
+
+// limitedReader wraps an io.Reader, returning an error once more than
+// limit bytes have been read since the last call to reset. Unlike
+// io.LimitReader, the limit can be reset so the same wrapped reader can
+// be reused to bound each array element in turn.
+type limitedReader struct {
+	r     io.Reader
+	limit int
+	read  int
+}
+
+func (l *limitedReader) reset() {
+	l.read = 0
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.read >= l.limit {
+		return 0, fmt.Errorf("element exceeds maximum JSON element size of %v bytes", l.limit)
+	}
+	if remaining := l.limit - l.read; len(p) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += n
+	return n, err
+}
+
+// JSONArrayConvertibleDoc implements the ConvertibleDoc interface for a
+// single, already-decoded element of a JSON array input source
+type JSONArrayConvertibleDoc struct {
+	doc bson.D
+}
+
+// NewJSONArrayInputReader returns a JSONArrayInputReader configured to
+// stream elements out of the JSON array contained in in.
+func NewJSONArrayInputReader(in io.Reader, numDecoders int) *JSONArrayInputReader {
+	szCount := &sizeTrackingReader{in, 0}
+	elementLimit := &limitedReader{r: szCount, limit: defaultMaxJSONElementSize}
+	decoder := json.NewDecoder(elementLimit)
+	decoder.UseNumber()
+	return &JSONArrayInputReader{
+		decoder:      decoder,
+		elementLimit: elementLimit,
+		numProcessed: uint64(0),
+		numDecoders:  numDecoders,
+		sizeTracker:  szCount,
+	}
+}
+
+// ReadAndValidateHeader consumes the opening '[' of the JSON array. There
+// is no field list to validate for JSON array input.
+func (jsonArrayInputReader *JSONArrayInputReader) ReadAndValidateHeader() error {
+	tok, err := jsonArrayInputReader.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected --jsonArray input to start with '[', got %v", tok)
+	}
+	return nil
+}
+
+// StreamDocument takes in two channels: it sends processed documents on the
+// readDocChan channel and if any error is encountered, the error is sent on
+// the errChan channel. It decodes one array element at a time, with
+// elementLimit bounding how many bytes may be read for that element as it
+// is decoded, so that input far larger than memory - or a single
+// pathologically large element - can't exhaust memory. Decoded elements
+// are streamed through the ConvertibleDoc/streamDocuments pipeline. If
+// ordered is true, it streams the documents in the order in which they
+// are read.
+func (jsonArrayInputReader *JSONArrayInputReader) StreamDocument(ordered bool, readDocChan chan bson.D, errChan chan error) {
+	docChan := make(chan ConvertibleDoc, jsonArrayInputReader.numDecoders)
+	go func() {
+		defer close(docChan)
+		for jsonArrayInputReader.decoder.More() {
+			jsonArrayInputReader.elementLimit.reset()
+			var raw json.RawMessage
+			if err := jsonArrayInputReader.decoder.Decode(&raw); err != nil {
+				errChan <- fmt.Errorf("read error on entry #%v: %v", jsonArrayInputReader.numProcessed, err)
+				return
+			}
+
+			elementDecoder := json.NewDecoder(bytes.NewReader(raw))
+			elementDecoder.UseNumber()
+			value, err := bsonutil.ConvertJSONValueToBSON(elementDecoder)
+			if err != nil {
+				errChan <- fmt.Errorf("entry #%v: %v", jsonArrayInputReader.numProcessed, err)
+				return
+			}
+			doc, ok := value.(bson.D)
+			if !ok {
+				errChan <- fmt.Errorf("entry #%v: expected a JSON object, got %T", jsonArrayInputReader.numProcessed, value)
+				return
+			}
+
+			docChan <- JSONArrayConvertibleDoc{doc: doc}
+			jsonArrayInputReader.numProcessed++
+		}
+
+		if _, err := jsonArrayInputReader.decoder.Token(); err != nil && err != io.EOF {
+			errChan <- fmt.Errorf("error reading closing ']' of JSON array: %v", err)
+		}
+	}()
+	errChan <- streamDocuments(ordered, jsonArrayInputReader.numDecoders, docChan, readDocChan)
+}
+
+// This is required to satisfy the ConvertibleDoc interface for JSON array
+// input. The element has already been decoded, so Convert is a no-op.
+func (jsonArrayConvertibleDoc JSONArrayConvertibleDoc) Convert() (bson.D, error) {
+	return jsonArrayConvertibleDoc.doc, nil
+}
@@ -0,0 +1,89 @@
+package mongoimport
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readAll drains a JSONArrayInputReader to completion, returning the
+// documents it streamed and any error it reported. StreamDocument signals
+// completion by sending on errChan rather than closing readDocChan, so
+// docs and the final error must be collected from the same select loop.
+// On success, every document send happens-before the error send within
+// StreamDocument, so once errChan is ready, no further document is
+// pending; an error found while decoding an element is sent as soon as it
+// is found, so documents decoded before it may not have been collected.
+func readAll(t *testing.T, r *JSONArrayInputReader) ([]bson.D, error) {
+	t.Helper()
+	if err := r.ReadAndValidateHeader(); err != nil {
+		return nil, err
+	}
+
+	docChan := make(chan bson.D)
+	errChan := make(chan error, 1)
+	go r.StreamDocument(true, docChan, errChan)
+
+	var docs []bson.D
+	for {
+		select {
+		case doc := <-docChan:
+			docs = append(docs, doc)
+		case err := <-errChan:
+			return docs, err
+		}
+	}
+}
+
+func TestJSONArrayInputReaderStreamsDocuments(t *testing.T) {
+	input := `[{"a": 1, "b": "x"}, {"a": 2, "b": "y"}]`
+	r := NewJSONArrayInputReader(strings.NewReader(input), 1)
+
+	docs, err := readAll(t, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []bson.D{
+		{{Name: "a", Value: int64(1)}, {Name: "b", Value: "x"}},
+		{{Name: "a", Value: int64(2)}, {Name: "b", Value: "y"}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Fatalf("got %#v, want %#v", docs, want)
+	}
+}
+
+func TestJSONArrayInputReaderEmptyArray(t *testing.T) {
+	r := NewJSONArrayInputReader(strings.NewReader(`[]`), 1)
+	docs, err := readAll(t, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(docs) != 0 {
+		t.Fatalf("got %#v, want no documents", docs)
+	}
+}
+
+func TestJSONArrayInputReaderRejectsNonArray(t *testing.T) {
+	r := NewJSONArrayInputReader(strings.NewReader(`{"a": 1}`), 1)
+	if err := r.ReadAndValidateHeader(); err == nil {
+		t.Fatal("expected an error for non-array input, got nil")
+	}
+}
+
+func TestJSONArrayInputReaderRejectsNonObjectElement(t *testing.T) {
+	r := NewJSONArrayInputReader(strings.NewReader(`[1, 2]`), 1)
+	if _, err := readAll(t, r); err == nil {
+		t.Fatal("expected an error for an array of non-objects, got nil")
+	}
+}
+
+func TestJSONArrayInputReaderEnforcesElementSizeLimit(t *testing.T) {
+	r := NewJSONArrayInputReader(strings.NewReader(`[{"a": "`+strings.Repeat("x", 100)+`"}]`), 1)
+	r.elementLimit.limit = 10
+
+	if _, err := readAll(t, r); err == nil {
+		t.Fatal("expected an error for an element exceeding the size limit, got nil")
+	}
+}
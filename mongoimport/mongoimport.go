@@ -0,0 +1,146 @@
+// Package mongoimport provides the input side of an import tool for
+// MongoDB, converting CSV, TSV, or JSON-array input into BSON documents.
+// JSON input is only read with --jsonArray; newline-delimited JSON is
+// not supported in this build.
+package mongoimport
+
+import (
+	"fmt"
+	"github.com/mongodb/mongo-tools/common/options"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+const (
+	csvInputType  = "csv"
+	tsvInputType  = "tsv"
+	jsonInputType = "json"
+)
+
+// singleCharString extracts the single rune represented by s, which is
+// expected to be exactly one character long (e.g. a delimiter, quote, or
+// escape flag value).
+func singleCharString(name, s string) (rune, error) {
+	runes := []rune(s)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("%v must be exactly one character, got %q", name, s)
+	}
+	return runes[0], nil
+}
+
+// numDecodingWorkers returns the number of concurrent goroutines to use
+// for decoding input, from --numDecodingWorkers, falling back to the
+// number of CPUs available if it is unset or not positive.
+func numDecodingWorkers(inputOptions *options.InputOptions) int {
+	if inputOptions.NumDecodingWorkers > 0 {
+		return inputOptions.NumDecodingWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// explicitFields returns the plain field name list supplied via --fields
+// or --fieldFile, or nil if neither was given, so the caller can fall
+// back to --headerline instead.
+func explicitFields(inputOptions *options.InputOptions) ([]string, error) {
+	if inputOptions.Fields != nil {
+		return strings.Split(*inputOptions.Fields, ","), nil
+	}
+	if inputOptions.FieldFile != nil {
+		data, err := os.ReadFile(*inputOptions.FieldFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading field file %v: %v", *inputOptions.FieldFile, err)
+		}
+		var fields []string
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if line == "" {
+				continue
+			}
+			fields = append(fields, line)
+		}
+		return fields, nil
+	}
+	return nil, nil
+}
+
+// applyFieldOptions resolves --headerline, --fields, and --fieldFile
+// against reader, reading a header line from the input source or parsing
+// an explicit field list as appropriate, and applying the result via
+// setColumns. It is a no-op if none of those options were given, leaving
+// reader's existing column configuration - whatever the caller passed to
+// its constructor - untouched.
+func applyFieldOptions(reader InputReader, setColumns func([]ColumnSpec), inputOptions *options.InputOptions, parseGrace ParseGrace) error {
+	if inputOptions.HeaderLine {
+		return reader.ReadAndValidateHeader()
+	}
+	fields, err := explicitFields(inputOptions)
+	if err != nil {
+		return err
+	}
+	if fields == nil {
+		return nil
+	}
+	columns, err := newColumnSpecs(fields, inputOptions.ColumnsHaveTypes, parseGrace)
+	if err != nil {
+		return err
+	}
+	if err := validateReaderFields(columns); err != nil {
+		return err
+	}
+	setColumns(columns)
+	return nil
+}
+
+// getInputReader returns an implementation of InputReader based on the
+// input type supplied in the given InputOptions, reading from in.
+func getInputReader(inputOptions *options.InputOptions, in io.Reader) (InputReader, error) {
+	parseGrace, err := ParseGraceFromString(inputOptions.ParseGrace)
+	if err != nil {
+		return nil, err
+	}
+
+	switch inputOptions.Type {
+	case csvInputType:
+		delim, err := singleCharString("--csvDelim", inputOptions.CSVDelimiter)
+		if err != nil {
+			return nil, err
+		}
+		quote, err := singleCharString("--quote", inputOptions.Quote)
+		if err != nil {
+			return nil, err
+		}
+		escape, err := singleCharString("--quoteEscape", inputOptions.QuoteEscape)
+		if err != nil {
+			return nil, err
+		}
+		csvReader := NewCSVInputReader(nil, in, numDecodingWorkers(inputOptions), delim, quote, escape, inputOptions.ColumnsHaveTypes, parseGrace)
+		if inputOptions.ReadBlockSize > 0 {
+			csvReader.readBlockSize = inputOptions.ReadBlockSize
+		}
+		if err := applyFieldOptions(csvReader, func(columns []ColumnSpec) { csvReader.columns = columns }, inputOptions, parseGrace); err != nil {
+			return nil, err
+		}
+		return csvReader, nil
+	case tsvInputType:
+		delim, err := singleCharString("--tsvDelim", inputOptions.TSVDelimiter)
+		if err != nil {
+			return nil, err
+		}
+		tsvReader := NewTSVInputReader(nil, in, numDecodingWorkers(inputOptions), delim, inputOptions.ColumnsHaveTypes, parseGrace)
+		if inputOptions.ReadBlockSize > 0 {
+			tsvReader.readBlockSize = inputOptions.ReadBlockSize
+		}
+		if err := applyFieldOptions(tsvReader, func(columns []ColumnSpec) { tsvReader.columns = columns }, inputOptions, parseGrace); err != nil {
+			return nil, err
+		}
+		return tsvReader, nil
+	case jsonInputType, "":
+		if inputOptions.JSONArray {
+			return NewJSONArrayInputReader(in, numDecodingWorkers(inputOptions)), nil
+		}
+		return nil, fmt.Errorf("JSON input is not implemented in this build")
+	}
+	return nil, fmt.Errorf("unsupported input type: %v", inputOptions.Type)
+}
@@ -0,0 +1,105 @@
+package mongoimport
+
+import (
+	"github.com/mongodb/mongo-tools/common/options"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestInputOptions(inputType string) *options.InputOptions {
+	return &options.InputOptions{
+		Type:         inputType,
+		TSVDelimiter: "\t",
+		CSVDelimiter: ",",
+		Quote:        "\"",
+		QuoteEscape:  "\"",
+	}
+}
+
+func TestGetInputReaderHeaderLine(t *testing.T) {
+	inputOptions := newTestInputOptions(csvInputType)
+	inputOptions.HeaderLine = true
+
+	reader, err := getInputReader(inputOptions, strings.NewReader("a,b\n1,2\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	docs, err := streamAll(reader, true)
+	if err != nil {
+		t.Fatalf("unexpected error streaming: %v", err)
+	}
+	if len(docs) != 1 || docs[0][0].Name != "a" || docs[0][1].Name != "b" {
+		t.Fatalf("got %#v, want a single document with fields a, b", docs)
+	}
+}
+
+func TestGetInputReaderFieldsOption(t *testing.T) {
+	fields := "a,b"
+	inputOptions := newTestInputOptions(tsvInputType)
+	inputOptions.Fields = &fields
+
+	reader, err := getInputReader(inputOptions, strings.NewReader("1\t2\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	docs, err := streamAll(reader, true)
+	if err != nil {
+		t.Fatalf("unexpected error streaming: %v", err)
+	}
+	if len(docs) != 1 || docs[0][0].Name != "a" || docs[0][1].Name != "b" {
+		t.Fatalf("got %#v, want a single document with fields a, b", docs)
+	}
+}
+
+func TestGetInputReaderFieldFileOption(t *testing.T) {
+	dir := t.TempDir()
+	fieldFile := filepath.Join(dir, "fields.txt")
+	if err := os.WriteFile(fieldFile, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatalf("failed to write field file: %v", err)
+	}
+
+	inputOptions := newTestInputOptions(csvInputType)
+	inputOptions.FieldFile = &fieldFile
+
+	reader, err := getInputReader(inputOptions, strings.NewReader("1,2\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	docs, err := streamAll(reader, true)
+	if err != nil {
+		t.Fatalf("unexpected error streaming: %v", err)
+	}
+	if len(docs) != 1 || docs[0][0].Name != "a" || docs[0][1].Name != "b" {
+		t.Fatalf("got %#v, want a single document with fields a, b", docs)
+	}
+}
+
+func TestGetInputReaderFieldFileOptionMissingFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "does-not-exist.txt")
+	inputOptions := newTestInputOptions(csvInputType)
+	inputOptions.FieldFile = &missing
+
+	if _, err := getInputReader(inputOptions, strings.NewReader("1,2\n")); err == nil {
+		t.Fatal("expected an error for a missing field file, got nil")
+	}
+}
+
+func TestGetInputReaderNoFieldOptionsLeavesDefaultColumns(t *testing.T) {
+	// absent --headerline, --fields, and --fieldFile, getInputReader
+	// should leave the reader's empty column list alone (tokens are then
+	// named field0, field1, ...) rather than erroring
+	inputOptions := newTestInputOptions(csvInputType)
+
+	reader, err := getInputReader(inputOptions, strings.NewReader("1,2\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := reader.(*CSVInputReader); !ok {
+		t.Fatalf("got %T, want *CSVInputReader", reader)
+	}
+}
@@ -0,0 +1,177 @@
+package mongoimport
+
+import (
+	"fmt"
+	"gopkg.in/mgo.v2/bson"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// setNestedField writes value into doc at the dotted path given by name,
+// e.g. "a.b.c" creates (or reuses) nested subdocuments for "a" and "a.b",
+// and "tags.0" creates (or reuses) an array at "tags" and sets its first
+// element. A plain, undotted name is equivalent to a normal top-level
+// assignment.
+func setNestedField(doc *bson.D, name string, value interface{}) error {
+	return setPath(doc, strings.Split(name, "."), value)
+}
+
+// setPath is the recursive implementation behind setNestedField.
+func setPath(doc *bson.D, segments []string, value interface{}) error {
+	seg := segments[0]
+	rest := segments[1:]
+
+	if len(rest) > 0 {
+		if idx, ok := arrayIndex(rest[0]); ok {
+			return setArrayPath(doc, seg, idx, rest[1:], value)
+		}
+	}
+
+	if len(rest) == 0 {
+		setElem(doc, seg, value)
+		return nil
+	}
+
+	var sub bson.D
+	if existing, ok := findElem(doc, seg); ok {
+		subDoc, ok := existing.(bson.D)
+		if !ok {
+			return fmt.Errorf("field %q is used both as a value and as a parent of nested fields", seg)
+		}
+		sub = subDoc
+	}
+	if err := setPath(&sub, rest, value); err != nil {
+		return err
+	}
+	setElem(doc, seg, sub)
+	return nil
+}
+
+// setArrayPath sets the element at idx within the array stored under seg
+// in doc, growing the array as needed. If rest is non-empty, the element
+// itself is a subdocument whose fields are set recursively.
+func setArrayPath(doc *bson.D, seg string, idx int, rest []string, value interface{}) error {
+	var arr []interface{}
+	if existing, ok := findElem(doc, seg); ok {
+		a, ok := existing.([]interface{})
+		if !ok {
+			return fmt.Errorf("field %q is used both as an array and as a non-array value", seg)
+		}
+		arr = a
+	}
+	for len(arr) <= idx {
+		arr = append(arr, nil)
+	}
+
+	if len(rest) == 0 {
+		arr[idx] = value
+	} else {
+		var sub bson.D
+		if arr[idx] != nil {
+			subDoc, ok := arr[idx].(bson.D)
+			if !ok {
+				return fmt.Errorf("field %q.%d is used both as a value and as a parent of nested fields", seg, idx)
+			}
+			sub = subDoc
+		}
+		if err := setPath(&sub, rest, value); err != nil {
+			return err
+		}
+		arr[idx] = sub
+	}
+
+	setElem(doc, seg, arr)
+	return nil
+}
+
+// arrayIndex reports whether s is a non-negative integer array subscript,
+// returning its value if so.
+func arrayIndex(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	idx, err := strconv.Atoi(s)
+	if err != nil || idx < 0 {
+		return 0, false
+	}
+	return idx, true
+}
+
+// findElem returns the value of the element named name in doc, if any.
+func findElem(doc *bson.D, name string) (interface{}, bool) {
+	for _, elem := range *doc {
+		if elem.Name == name {
+			return elem.Value, true
+		}
+	}
+	return nil, false
+}
+
+// setElem sets (or appends) the element named name in doc to value.
+func setElem(doc *bson.D, name string, value interface{}) {
+	for i, elem := range *doc {
+		if elem.Name == name {
+			(*doc)[i].Value = value
+			return
+		}
+	}
+	*doc = append(*doc, bson.DocElem{Name: name, Value: value})
+}
+
+// validateNestedFieldPaths checks that a set of dotted/array field names
+// is internally consistent: no name is used both as a leaf value and as
+// the parent of nested fields, and array indices under any given prefix
+// are contiguous starting at 0 - including arrays nested underneath a
+// shared prefix, e.g. "a.0.b" and "a.2.b".
+func validateNestedFieldPaths(names []string) error {
+	prefixes := map[string]bool{}
+	leaves := map[string]bool{}
+	arrayIndices := map[string]map[int]bool{}
+
+	for _, name := range names {
+		segments := strings.Split(name, ".")
+		for i := 1; i < len(segments); i++ {
+			prefixes[strings.Join(segments[:i], ".")] = true
+		}
+
+		// record every array-index segment encountered along the path,
+		// not just a terminal one, so that contiguity is checked for
+		// arrays nested under a prefix, not only for arrays whose index
+		// is the last segment of the name
+		for i := 1; i < len(segments); i++ {
+			idx, ok := arrayIndex(segments[i])
+			if !ok {
+				continue
+			}
+			parent := strings.Join(segments[:i], ".")
+			if arrayIndices[parent] == nil {
+				arrayIndices[parent] = map[int]bool{}
+			}
+			arrayIndices[parent][idx] = true
+		}
+
+		leaves[name] = true
+	}
+
+	for leaf := range leaves {
+		if prefixes[leaf] {
+			return fmt.Errorf("field %q is used both as a value and as a parent of nested fields", leaf)
+		}
+	}
+
+	for prefix, indexSet := range arrayIndices {
+		indices := make([]int, 0, len(indexSet))
+		for idx := range indexSet {
+			indices = append(indices, idx)
+		}
+		sort.Ints(indices)
+		for i, idx := range indices {
+			if idx != i {
+				return fmt.Errorf("array field %q has non-contiguous indices: expected %d, found %d", prefix, i, idx)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package mongoimport
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"testing"
+)
+
+func TestSetNestedFieldDottedPaths(t *testing.T) {
+	doc := bson.D{}
+	if err := setNestedField(&doc, "addr.city", "Springfield"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := setNestedField(&doc, "addr.zip", "00000"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := bson.D{
+		{Name: "addr", Value: bson.D{
+			{Name: "city", Value: "Springfield"},
+			{Name: "zip", Value: "00000"},
+		}},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("got %#v, want %#v", doc, want)
+	}
+}
+
+func TestSetNestedFieldArrayPaths(t *testing.T) {
+	doc := bson.D{}
+	if err := setNestedField(&doc, "tags.0", "a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := setNestedField(&doc, "tags.1", "b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := bson.D{
+		{Name: "tags", Value: []interface{}{"a", "b"}},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("got %#v, want %#v", doc, want)
+	}
+}
+
+func TestSetNestedFieldArrayOfSubdocuments(t *testing.T) {
+	doc := bson.D{}
+	if err := setNestedField(&doc, "items.0.name", "widget"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := setNestedField(&doc, "items.0.price", "9.99"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	items, ok := findElem(&doc, "items")
+	if !ok {
+		t.Fatalf("expected an items field, got %#v", doc)
+	}
+	arr, ok := items.([]interface{})
+	if !ok || len(arr) != 1 {
+		t.Fatalf("expected a one-element array, got %#v", items)
+	}
+	sub, ok := arr[0].(bson.D)
+	if !ok {
+		t.Fatalf("expected array element to be a bson.D, got %T", arr[0])
+	}
+	if name, _ := findElem(&sub, "name"); name != "widget" {
+		t.Fatalf("got name %#v, want %q", name, "widget")
+	}
+}
+
+func TestSetNestedFieldConflict(t *testing.T) {
+	doc := bson.D{}
+	if err := setNestedField(&doc, "a", "scalar"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := setNestedField(&doc, "a.b", "nested"); err == nil {
+		t.Fatal("expected an error when a field is used both as a scalar and a parent, got nil")
+	}
+}
+
+func TestValidateNestedFieldPaths(t *testing.T) {
+	cases := []struct {
+		name    string
+		fields  []string
+		wantErr bool
+	}{
+		{name: "plain fields", fields: []string{"name", "age"}},
+		{name: "dotted subdocument fields", fields: []string{"addr.city", "addr.zip"}},
+		{name: "contiguous array indices", fields: []string{"tags.0", "tags.1", "tags.2"}},
+		{name: "non-contiguous array indices", fields: []string{"tags.0", "tags.2"}, wantErr: true},
+		{name: "array not starting at 0", fields: []string{"tags.1", "tags.2"}, wantErr: true},
+		{
+			name:   "contiguous nested array under a shared prefix",
+			fields: []string{"items.0.name", "items.1.name"},
+		},
+		{
+			// items.0.b and items.2.b share the "items" prefix but skip
+			// index 1, so "items" is a non-contiguous array
+			name:    "non-contiguous nested array under a shared prefix",
+			fields:  []string{"items.0.b", "items.2.b"},
+			wantErr: true,
+		},
+		{
+			name:    "field used both as value and parent",
+			fields:  []string{"a", "a.b"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateNestedFieldPaths(c.fields)
+			if c.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !c.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
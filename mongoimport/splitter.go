@@ -0,0 +1,245 @@
+package mongoimport
+
+import (
+	"bytes"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+// defaultReadBlockSize is the size, in bytes, of each block read from the
+// underlying input source when no --readBlockSize is given
+const defaultReadBlockSize = 1 << 20 // 1MB
+
+// blockBufPool recycles the byte slices used to hold raw input blocks, to
+// reduce allocation pressure on large imports
+var blockBufPool = sync.Pool{
+	New: func() interface{} {
+		return make([]byte, 0, defaultReadBlockSize)
+	},
+}
+
+// rowsPool recycles the [][]string slices used to hold a block's decoded
+// rows before they are converted to BSON
+var rowsPool = sync.Pool{
+	New: func() interface{} {
+		return make([][]string, 0, 1024)
+	},
+}
+
+// rawBlock pairs a raw, row-aligned chunk of input with its position in
+// the stream, so that blocks decoded out of order by the worker pool can
+// be reassembled in their original order
+type rawBlock struct {
+	seq  int
+	data []byte
+}
+
+// decodedBlock pairs a block's decoded rows with its position in the
+// stream
+type decodedBlock struct {
+	seq  int
+	rows [][]string
+	err  error
+}
+
+// splitBlocks reads fixed-size blocks from r and sends each one, aligned
+// on a row boundary found by findBoundary, to blockChan. findBoundary is
+// given the bytes read so far and returns the length of the leading
+// portion that ends on a safe row boundary; anything after that point is
+// carried over and prepended to the next block read. If findBoundary
+// cannot find a boundary (returns a negative value) and more input
+// remains, the whole buffer is carried over and grown on the next read,
+// so a single oversized row never gets split.
+//
+// splitBlocks closes blockChan when it is done, and reports any read
+// error other than io.EOF on errChan.
+func splitBlocks(r io.Reader, readBlockSize int, findBoundary func([]byte) int, blockChan chan<- rawBlock, errChan chan<- error) {
+	defer close(blockChan)
+
+	if readBlockSize <= 0 {
+		readBlockSize = defaultReadBlockSize
+	}
+
+	seq := 0
+	var carry []byte
+	for {
+		buf := blockBufPool.Get().([]byte)
+		need := readBlockSize
+		if len(carry) > need {
+			need = len(carry) * 2
+		}
+		if cap(buf) < need+len(carry) {
+			buf = make([]byte, 0, need+len(carry))
+		}
+		buf = buf[:len(carry)]
+		copy(buf, carry)
+
+		readTo := len(buf) + readBlockSize
+		if cap(buf) < readTo {
+			grown := make([]byte, len(buf), readTo)
+			copy(grown, buf)
+			buf = grown
+		}
+		n, err := io.ReadFull(r, buf[len(buf):readTo])
+		buf = buf[:len(buf)+n]
+
+		isFinal := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !isFinal {
+			errChan <- err
+			return
+		}
+
+		if len(buf) == 0 {
+			return
+		}
+
+		cut := len(buf)
+		if !isFinal {
+			if boundary := findBoundary(buf); boundary >= 0 {
+				cut = boundary
+			} else {
+				// no safe boundary yet (e.g. a single row bigger than
+				// readBlockSize) - carry the whole buffer forward and
+				// read a larger block next time
+				carry = buf
+				continue
+			}
+		}
+
+		block := make([]byte, cut)
+		copy(block, buf[:cut])
+		blockChan <- rawBlock{seq: seq, data: block}
+		seq++
+
+		if cut < len(buf) {
+			leftover := make([]byte, len(buf)-cut)
+			copy(leftover, buf[cut:])
+			carry = leftover
+		} else {
+			carry = nil
+		}
+		blockBufPool.Put(buf[:0])
+
+		if isFinal {
+			return
+		}
+	}
+}
+
+// decodeBlocksAndStream runs numDecoders workers that decode raw blocks
+// from blockChan into token rows (via decodeBlock) and then into BSON
+// documents (via columns), sending the results on readDocChan. If ordered
+// is true, documents are emitted in the same order their source blocks
+// were read.
+func decodeBlocksAndStream(ordered bool, numDecoders int, blockChan <-chan rawBlock, decodeBlock func([]byte) ([][]string, error), columns []ColumnSpec, readDocChan chan bson.D) error {
+	if numDecoders <= 0 {
+		numDecoders = 1
+	}
+
+	decodedChan := make(chan decodedBlock, numDecoders)
+	var wg sync.WaitGroup
+	wg.Add(numDecoders)
+	for i := 0; i < numDecoders; i++ {
+		go func() {
+			defer wg.Done()
+			for block := range blockChan {
+				rows, err := decodeBlock(block.data)
+				decodedChan <- decodedBlock{seq: block.seq, rows: rows, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(decodedChan)
+	}()
+
+	var retErr error
+	var rowNum uint64
+	emit := func(blk decodedBlock) {
+		if blk.err != nil {
+			if retErr == nil {
+				retErr = blk.err
+			}
+			return
+		}
+		for _, row := range blk.rows {
+			doc, err := tokensToBSON(columns, row, rowNum)
+			rowNum++
+			if err != nil {
+				if err != errSkipRow && retErr == nil {
+					retErr = err
+				}
+				continue
+			}
+			readDocChan <- doc
+		}
+		rowsPool.Put(blk.rows[:0])
+	}
+
+	if !ordered {
+		for blk := range decodedChan {
+			emit(blk)
+		}
+		return retErr
+	}
+
+	pending := map[int]decodedBlock{}
+	next := 0
+	for blk := range decodedChan {
+		pending[blk.seq] = blk
+		for {
+			blk, ok := pending[next]
+			if !ok {
+				break
+			}
+			emit(blk)
+			delete(pending, next)
+			next++
+		}
+	}
+	return retErr
+}
+
+// findLineBoundary returns the index just after the last occurrence of
+// '\n' in buf, or -1 if buf contains no newline. It is used to align TSV
+// blocks on row boundaries, since TSV rows never contain embedded
+// newlines.
+func findLineBoundary(buf []byte) int {
+	idx := bytes.LastIndexByte(buf, '\n')
+	if idx < 0 {
+		return -1
+	}
+	return idx + 1
+}
+
+// csvLineBoundaryFinder returns a findBoundary function that locates the
+// last newline in a block that falls outside a quoted field, so that CSV
+// blocks are never cut in the middle of a quoted, embedded newline.
+func csvLineBoundaryFinder(quote, escape rune) func([]byte) int {
+	return func(buf []byte) int {
+		inQuotes := false
+		lastSafe := -1
+		for i := 0; i < len(buf); {
+			r, size := utf8.DecodeRune(buf[i:])
+			switch {
+			case r == escape && inQuotes && escape != quote && i+size < len(buf):
+				if r2, size2 := utf8.DecodeRune(buf[i+size:]); r2 == quote {
+					i += size + size2
+					continue
+				}
+				i += size
+			case r == quote:
+				inQuotes = !inQuotes
+				i += size
+			case r == '\n' && !inQuotes:
+				lastSafe = i + size
+				i += size
+			default:
+				i += size
+			}
+		}
+		return lastSafe
+	}
+}
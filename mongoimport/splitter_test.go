@@ -0,0 +1,125 @@
+package mongoimport
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// collectBlocks drains blockChan into a slice, for tests that want to
+// inspect how splitBlocks aligned block boundaries.
+func collectBlocks(blockChan <-chan rawBlock) []rawBlock {
+	var blocks []rawBlock
+	for block := range blockChan {
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func TestSplitBlocksAlignsOnLineBoundary(t *testing.T) {
+	input := "aaa\nbbb\nccc\nddd\n"
+	blockChan := make(chan rawBlock, 16)
+	errChan := make(chan error, 1)
+
+	// a small readBlockSize forces splitBlocks to carry partial lines
+	// forward across multiple reads
+	splitBlocks(strings.NewReader(input), 5, findLineBoundary, blockChan, errChan)
+
+	blocks := collectBlocks(blockChan)
+	select {
+	case err := <-errChan:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	var rebuilt strings.Builder
+	for i, block := range blocks {
+		if block.seq != i {
+			t.Fatalf("block %d has out-of-order seq %d", i, block.seq)
+		}
+		if !strings.HasSuffix(string(block.data), "\n") {
+			t.Fatalf("block %d does not end on a line boundary: %q", i, block.data)
+		}
+		rebuilt.Write(block.data)
+	}
+	if rebuilt.String() != input {
+		t.Fatalf("got %q, want %q", rebuilt.String(), input)
+	}
+}
+
+func TestSplitBlocksCarriesOversizedLine(t *testing.T) {
+	// the middle line is far larger than readBlockSize, so splitBlocks
+	// must keep growing its buffer rather than cutting the line in half
+	input := "a\n" + strings.Repeat("x", 100) + "\n" + "b\n"
+	blockChan := make(chan rawBlock, 16)
+	errChan := make(chan error, 1)
+
+	splitBlocks(strings.NewReader(input), 8, findLineBoundary, blockChan, errChan)
+
+	blocks := collectBlocks(blockChan)
+	select {
+	case err := <-errChan:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	var rebuilt strings.Builder
+	for _, block := range blocks {
+		rebuilt.Write(block.data)
+	}
+	if rebuilt.String() != input {
+		t.Fatalf("got %q, want %q", rebuilt.String(), input)
+	}
+}
+
+// splitLinesDecoder is a minimal decodeBlock function, independent of
+// CSV/TSV, used to exercise decodeBlocksAndStream's ordering and worker
+// pool logic in isolation.
+func splitLinesDecoder(data []byte) ([][]string, error) {
+	var rows [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		rows = append(rows, []string{line})
+	}
+	return rows, nil
+}
+
+func TestDecodeBlocksAndStreamOrderedMatchesSingleDecoder(t *testing.T) {
+	var lines []string
+	for i := 0; i < 500; i++ {
+		lines = append(lines, strings.Repeat("v", i%7+1))
+	}
+	input := strings.Join(lines, "\n") + "\n"
+	columns := newAutoColumnSpecs([]string{"a"}, PGAutoCast)
+
+	runWithDecoders := func(numDecoders int) []bson.D {
+		blockChan := make(chan rawBlock, 16)
+		splitErrChan := make(chan error, 1)
+		go splitBlocks(strings.NewReader(input), 16, findLineBoundary, blockChan, splitErrChan)
+
+		readDocChan := make(chan bson.D, len(lines))
+		err := decodeBlocksAndStream(true, numDecoders, blockChan, splitLinesDecoder, columns, readDocChan)
+		close(readDocChan)
+		if err != nil {
+			t.Fatalf("unexpected error with %d decoders: %v", numDecoders, err)
+		}
+		var docs []bson.D
+		for doc := range readDocChan {
+			docs = append(docs, doc)
+		}
+		return docs
+	}
+
+	want := runWithDecoders(1)
+	got := runWithDecoders(8)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ordered output with multiple decoders diverged from single-decoder output")
+	}
+	if len(want) != len(lines) {
+		t.Fatalf("got %d docs, want %d", len(want), len(lines))
+	}
+}
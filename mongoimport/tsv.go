@@ -2,7 +2,6 @@ package mongoimport
 
 import (
 	"bufio"
-	"fmt"
 	"gopkg.in/mgo.v2/bson"
 	"io"
 	"strings"
@@ -10,24 +9,38 @@ import (
 
 const (
 	entryDelimiter = '\n'
-	tokenSeparator = "\t"
+
+	// defaultTokenSeparator is the field delimiter used when none is
+	// configured on the TSVInputReader
+	defaultTokenSeparator = "\t"
 )
 
 // TSVInputReader is a struct that implements the InputReader interface for a
 // TSV input source
 type TSVInputReader struct {
-	// fields is a list of field names in the BSON documents to be imported
-	fields []string
+	// columns holds the name and parser for each BSON field to be
+	// imported, in column order
+	columns []ColumnSpec
+
+	// columnsHaveTypes indicates that the header line (or --fields
+	// list) carries a type annotation for each column, e.g. "age.int32()"
+	columnsHaveTypes bool
+
+	// parseGrace controls what happens when a token fails to parse
+	// according to its column's type
+	parseGrace ParseGrace
 
 	// tsvReader is the underlying reader used to read data in from the TSV
 	// or TSV file
 	tsvReader *bufio.Reader
 
-	// tsvRecord stores each line of input we read from the underlying reader
-	tsvRecord string
+	// tokenSeparator is the field delimiter used to split each line into
+	// tokens; it defaults to a tab character
+	tokenSeparator string
 
-	// numProcessed tracks the number of TSV records processed by the underlying reader
-	numProcessed uint64
+	// readBlockSize is the size, in bytes, of each block StreamDocument
+	// reads from the underlying input source for parallel decoding
+	readBlockSize int
 
 	// numDecoders is the number of concurrent goroutines to use for decoding
 	numDecoders int
@@ -38,64 +51,103 @@ type TSVInputReader struct {
 
 // TSVConvertibleDoc implements the ConvertibleDoc interface for TSV input
 type TSVConvertibleDoc struct {
-	fields []string
-	data   string
-	index  uint64
+	columns        []ColumnSpec
+	data           string
+	tokenSeparator string
+	index          uint64
 }
 
 // NewTSVInputReader returns a TSVInputReader configured to read input from the
-// given io.Reader, extracting the specified fields only.
-func NewTSVInputReader(fields []string, in io.Reader, numDecoders int) *TSVInputReader {
+// given io.Reader, extracting the specified fields only. delim configures
+// the field separator used to split each line into tokens. If
+// columnsHaveTypes is true, fields (whether passed in directly or read
+// from a header line) are expected to carry a type annotation, and
+// parseGrace governs how parse failures for those types are handled.
+func NewTSVInputReader(fields []string, in io.Reader, numDecoders int, delim rune, columnsHaveTypes bool, parseGrace ParseGrace) *TSVInputReader {
 	szCount := &sizeTrackingReader{in, 0}
+	tokenSeparator := defaultTokenSeparator
+	if delim != 0 {
+		tokenSeparator = string(delim)
+	}
 	return &TSVInputReader{
-		fields:       fields,
-		tsvReader:    bufio.NewReader(in),
-		numProcessed: uint64(0),
-		numDecoders:  numDecoders,
-		sizeTracker:  szCount,
+		columns:          newAutoColumnSpecs(fields, parseGrace),
+		columnsHaveTypes: columnsHaveTypes,
+		parseGrace:       parseGrace,
+		tsvReader:        bufio.NewReader(szCount),
+		tokenSeparator:   tokenSeparator,
+		readBlockSize:    defaultReadBlockSize,
+		numDecoders:      numDecoders,
+		sizeTracker:      szCount,
 	}
 }
 
-// ReadAndValidateHeader sets the import fields for a TSV importer
+// ReadAndValidateHeader sets the import fields for a TSV importer. If
+// columnsHaveTypes is set, each header token is expected to carry a type
+// annotation, e.g. "age.int32()".
 func (tsvInputReader *TSVInputReader) ReadAndValidateHeader() (err error) {
 	header, err := tsvInputReader.tsvReader.ReadString(entryDelimiter)
 	if err != nil {
 		return err
 	}
-	for _, field := range strings.Split(header, tokenSeparator) {
-		tsvInputReader.fields = append(tsvInputReader.fields, strings.TrimRight(field, "\r\n"))
+	var columns []ColumnSpec
+	for _, field := range strings.Split(header, tsvInputReader.tokenSeparator) {
+		field = strings.TrimRight(field, "\r\n")
+		if tsvInputReader.columnsHaveTypes {
+			column, err := parseTypedHeader(field, tsvInputReader.parseGrace)
+			if err != nil {
+				return err
+			}
+			columns = append(columns, column)
+			continue
+		}
+		columns = append(columns, ColumnSpec{Name: field, Parser: autoParser{}, ParseGrace: tsvInputReader.parseGrace})
 	}
-	return validateReaderFields(tsvInputReader.fields)
+	tsvInputReader.columns = columns
+	return validateReaderFields(tsvInputReader.columns)
 }
 
 // StreamDocument takes in two channels: it sends processed documents on the
-// readDocChan channel and if any error is encountered, the error is sent on the
-// errChan channel. It keeps reading from the underlying input source until it
-// hits EOF or an error. If ordered is true, it streams the documents in which
-// the documents are read
+// readDocChan channel and if any error is encountered, the error is sent on
+// the errChan channel. A splitter goroutine reads fixed-size blocks from
+// the underlying input source, aligning each block on the last newline it
+// contains, and hands the blocks to a pool of numDecoders goroutines that
+// each decode their block into documents independently. If ordered is
+// true, documents are streamed in the order the rows were read, even
+// though blocks may finish decoding out of order.
 func (tsvInputReader *TSVInputReader) StreamDocument(ordered bool, readDocChan chan bson.D, errChan chan error) {
-	tsvRecordChan := make(chan ConvertibleDoc, tsvInputReader.numDecoders)
-	go func() {
-		var err error
-		for {
-			tsvInputReader.tsvRecord, err = tsvInputReader.tsvReader.ReadString(entryDelimiter)
-			if err != nil {
-				if err != io.EOF {
-					tsvInputReader.numProcessed++
-					errChan <- fmt.Errorf("read error on entry #%v: %v", tsvInputReader.numProcessed, err)
-				}
-				close(tsvRecordChan)
-				return
-			}
-			tsvRecordChan <- TSVConvertibleDoc{
-				fields: tsvInputReader.fields,
-				data:   tsvInputReader.tsvRecord,
-				index:  tsvInputReader.numProcessed,
-			}
-			tsvInputReader.numProcessed++
+	tokenSeparator := tsvInputReader.tokenSeparator
+
+	blockChan := make(chan rawBlock, tsvInputReader.numDecoders)
+	splitErrChan := make(chan error, 1)
+	go splitBlocks(tsvInputReader.tsvReader, tsvInputReader.readBlockSize, findLineBoundary, blockChan, splitErrChan)
+
+	decodeBlock := func(data []byte) ([][]string, error) {
+		lines := strings.Split(string(data), "\n")
+		// splitting on "\n" leaves one trailing empty element for the
+		// newline that ends the block; drop it so it isn't decoded as a
+		// spurious blank row. Blank lines elsewhere in the block are
+		// still turned into a single-empty-field record, matching the
+		// CSV reader's treatment of blank lines.
+		if len(lines) > 0 && lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		rows := rowsPool.Get().([][]string)[:0]
+		for _, line := range lines {
+			line = strings.TrimRight(line, "\r")
+			rows = append(rows, strings.Split(line, tokenSeparator))
 		}
-	}()
-	errChan <- streamDocuments(ordered, tsvInputReader.numDecoders, tsvRecordChan, readDocChan)
+		return rows, nil
+	}
+
+	err := decodeBlocksAndStream(ordered, tsvInputReader.numDecoders, blockChan, decodeBlock, tsvInputReader.columns, readDocChan)
+	select {
+	case splitErr := <-splitErrChan:
+		if err == nil {
+			err = splitErr
+		}
+	default:
+	}
+	errChan <- err
 }
 
 // This is required to satisfy the ConvertibleDoc interface for TSV input. It
@@ -103,10 +155,10 @@ func (tsvInputReader *TSVInputReader) StreamDocument(ordered bool, readDocChan c
 func (tsvConvertibleDoc TSVConvertibleDoc) Convert() (bson.D, error) {
 	tsvTokens := strings.Split(
 		strings.TrimRight(tsvConvertibleDoc.data, "\r\n"),
-		tokenSeparator,
+		tsvConvertibleDoc.tokenSeparator,
 	)
 	return tokensToBSON(
-		tsvConvertibleDoc.fields,
+		tsvConvertibleDoc.columns,
 		tsvTokens,
 		tsvConvertibleDoc.index,
 	)
@@ -0,0 +1,63 @@
+package mongoimport
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestTSVReader(t *testing.T, input string, numDecoders, readBlockSize int) *TSVInputReader {
+	t.Helper()
+	r := NewTSVInputReader([]string{"a", "b"}, strings.NewReader(input), numDecoders, 0, false, PGAutoCast)
+	r.readBlockSize = readBlockSize
+	return r
+}
+
+func TestTSVStreamDocumentMatchesAcrossDecoderCounts(t *testing.T) {
+	var rows []string
+	for i := 0; i < 300; i++ {
+		rows = append(rows, "x\ty")
+	}
+	input := strings.Join(rows, "\n") + "\n"
+
+	// a tiny readBlockSize forces many small blocks, exercising the
+	// carry-forward path in splitBlocks, while numDecoders>1 forces
+	// blocks to be decoded out of order and reassembled
+	single, err := streamAll(newTestTSVReader(t, input, 1, 16), true)
+	if err != nil {
+		t.Fatalf("unexpected error with 1 decoder: %v", err)
+	}
+	multi, err := streamAll(newTestTSVReader(t, input, 8, 16), true)
+	if err != nil {
+		t.Fatalf("unexpected error with 8 decoders: %v", err)
+	}
+
+	if !reflect.DeepEqual(single, multi) {
+		t.Fatalf("ordered output with multiple decoders diverged from single-decoder output")
+	}
+	if len(single) != len(rows) {
+		t.Fatalf("got %d docs, want %d", len(single), len(rows))
+	}
+}
+
+func TestTSVStreamDocumentBlankLine(t *testing.T) {
+	// TSV rows never contain embedded newlines, so unlike CSV there is
+	// no boundary-straddling case to cover here; blank-line handling is
+	// the behavior that must match CSV's
+	input := "1\t2\n\n3\t4\n"
+
+	docs, err := streamAll(newTestTSVReader(t, input, 1, defaultReadBlockSize), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []bson.D{
+		{{Name: "a", Value: 1.0}, {Name: "b", Value: 2.0}},
+		{{Name: "a", Value: ""}},
+		{{Name: "a", Value: 3.0}, {Name: "b", Value: 4.0}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Fatalf("got %#v, want %#v", docs, want)
+	}
+}
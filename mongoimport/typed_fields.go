@@ -0,0 +1,227 @@
+package mongoimport
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseGrace controls what happens when a token fails to parse according
+// to the type specified for its column.
+type ParseGrace int
+
+const (
+	// PGAutoCast falls back to importing the field as a plain string
+	// when typed parsing fails
+	PGAutoCast ParseGrace = iota
+
+	// PGSkipField drops the offending field from the document, leaving
+	// the rest of the row intact
+	PGSkipField
+
+	// PGSkipRow discards the entire row that contains the offending
+	// field
+	PGSkipRow
+
+	// PGStop aborts the import with the parse error
+	PGStop
+)
+
+// ParseGraceFromString maps the string value of the --parseGrace flag to
+// a ParseGrace
+func ParseGraceFromString(s string) (ParseGrace, error) {
+	switch s {
+	case "", "stop":
+		return PGStop, nil
+	case "autoCast":
+		return PGAutoCast, nil
+	case "skipField":
+		return PGSkipField, nil
+	case "skipRow":
+		return PGSkipRow, nil
+	}
+	return PGStop, fmt.Errorf("invalid parseGrace value: %v", s)
+}
+
+// FieldParser converts a raw token into the value it should be stored as
+// in the resulting BSON document.
+type FieldParser interface {
+	Parse(token string) (interface{}, error)
+}
+
+// ColumnSpec describes how a single CSV/TSV column should be named and
+// parsed. When --columnsHaveTypes is not given, every ColumnSpec uses an
+// autoParser, preserving the historical auto-detected-type behavior.
+type ColumnSpec struct {
+	Name       string
+	Parser     FieldParser
+	ParseGrace ParseGrace
+}
+
+// newAutoColumnSpecs builds untyped ColumnSpecs for a plain field list,
+// i.e. the behavior mongoimport has always had absent --columnsHaveTypes.
+func newAutoColumnSpecs(fields []string, parseGrace ParseGrace) []ColumnSpec {
+	specs := make([]ColumnSpec, len(fields))
+	for i, field := range fields {
+		specs[i] = ColumnSpec{Name: field, Parser: autoParser{}, ParseGrace: parseGrace}
+	}
+	return specs
+}
+
+// newColumnSpecs builds the ColumnSpecs for an explicitly supplied field
+// list, e.g. one parsed from --fields or --fieldFile, honoring
+// --columnsHaveTypes the same way a parsed header line does.
+func newColumnSpecs(fields []string, columnsHaveTypes bool, parseGrace ParseGrace) ([]ColumnSpec, error) {
+	if !columnsHaveTypes {
+		return newAutoColumnSpecs(fields, parseGrace), nil
+	}
+	columns := make([]ColumnSpec, len(fields))
+	for i, field := range fields {
+		column, err := parseTypedHeader(field, parseGrace)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+// columnTypeHeaderRE matches a typed header token, e.g.
+// "name.string()", "age.int32()", "joined.date(2006-01-02)",
+// "tags.array(string)"
+var columnTypeHeaderRE = regexp.MustCompile(`^(.+)\.(\w+)\((.*)\)$`)
+
+// parseTypedHeader parses a single typed header token into a ColumnSpec.
+func parseTypedHeader(token string, parseGrace ParseGrace) (ColumnSpec, error) {
+	matches := columnTypeHeaderRE.FindStringSubmatch(token)
+	if matches == nil {
+		return ColumnSpec{}, fmt.Errorf("header field %q is missing a type annotation, e.g. %q", token, token+".string()")
+	}
+	name, typeName, arg := matches[1], matches[2], matches[3]
+
+	parser, err := newFieldParser(typeName, arg)
+	if err != nil {
+		return ColumnSpec{}, fmt.Errorf("header field %q: %v", token, err)
+	}
+	return ColumnSpec{Name: name, Parser: parser, ParseGrace: parseGrace}, nil
+}
+
+// newFieldParser returns the FieldParser for the given type name and its
+// parenthesized argument, e.g. typeName "date" with arg "2006-01-02", or
+// typeName "array" with arg "string".
+func newFieldParser(typeName, arg string) (FieldParser, error) {
+	switch typeName {
+	case "string":
+		return stringParser{}, nil
+	case "int32":
+		return int32Parser{}, nil
+	case "int64":
+		return int64Parser{}, nil
+	case "float", "double":
+		return floatParser{}, nil
+	case "boolean", "bool":
+		return booleanParser{}, nil
+	case "date":
+		if arg == "" {
+			return nil, fmt.Errorf("date() requires a reference time layout argument")
+		}
+		return dateParser{layout: arg}, nil
+	case "auto":
+		return autoParser{}, nil
+	case "array":
+		elementParser, err := newFieldParser(arg, "")
+		if err != nil {
+			return nil, fmt.Errorf("array(%v): %v", arg, err)
+		}
+		return arrayParser{element: elementParser}, nil
+	}
+	return nil, fmt.Errorf("unrecognized column type: %v", typeName)
+}
+
+type autoParser struct{}
+
+func (autoParser) Parse(token string) (interface{}, error) {
+	return getParsedValue(token), nil
+}
+
+type stringParser struct{}
+
+func (stringParser) Parse(token string) (interface{}, error) {
+	return token, nil
+}
+
+type int32Parser struct{}
+
+func (int32Parser) Parse(token string) (interface{}, error) {
+	value, err := strconv.ParseInt(token, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid int32 value %q: %v", token, err)
+	}
+	return int32(value), nil
+}
+
+type int64Parser struct{}
+
+func (int64Parser) Parse(token string) (interface{}, error) {
+	value, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid int64 value %q: %v", token, err)
+	}
+	return value, nil
+}
+
+type floatParser struct{}
+
+func (floatParser) Parse(token string) (interface{}, error) {
+	value, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid float value %q: %v", token, err)
+	}
+	return value, nil
+}
+
+type booleanParser struct{}
+
+func (booleanParser) Parse(token string) (interface{}, error) {
+	value, err := strconv.ParseBool(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid boolean value %q: %v", token, err)
+	}
+	return value, nil
+}
+
+type dateParser struct {
+	layout string
+}
+
+func (p dateParser) Parse(token string) (interface{}, error) {
+	value, err := time.Parse(p.layout, token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date value %q for layout %q: %v", token, p.layout, err)
+	}
+	return value, nil
+}
+
+// arrayParser parses a token as a comma-separated list of elements, each
+// parsed by the wrapped element parser.
+type arrayParser struct {
+	element FieldParser
+}
+
+func (p arrayParser) Parse(token string) (interface{}, error) {
+	if token == "" {
+		return []interface{}{}, nil
+	}
+	rawElements := strings.Split(token, ",")
+	values := make([]interface{}, len(rawElements))
+	for i, raw := range rawElements {
+		value, err := p.element.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,179 @@
+package mongoimport
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseGraceFromString(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    ParseGrace
+		wantErr bool
+	}{
+		{input: "", want: PGStop},
+		{input: "stop", want: PGStop},
+		{input: "autoCast", want: PGAutoCast},
+		{input: "skipField", want: PGSkipField},
+		{input: "skipRow", want: PGSkipRow},
+		{input: "bogus", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseGraceFromString(c.input)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("ParseGraceFromString(%q): expected an error, got nil", c.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseGraceFromString(%q): unexpected error: %v", c.input, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("ParseGraceFromString(%q) = %v, want %v", c.input, got, c.want)
+		}
+	}
+}
+
+func TestFieldParsers(t *testing.T) {
+	cases := []struct {
+		name    string
+		parser  FieldParser
+		token   string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "string", parser: stringParser{}, token: "42", want: "42"},
+		{name: "int32 valid", parser: int32Parser{}, token: "42", want: int32(42)},
+		{name: "int32 invalid", parser: int32Parser{}, token: "abc", wantErr: true},
+		{name: "int64 valid", parser: int64Parser{}, token: "42", want: int64(42)},
+		{name: "float valid", parser: floatParser{}, token: "3.14", want: 3.14},
+		{name: "float invalid", parser: floatParser{}, token: "abc", wantErr: true},
+		{name: "boolean valid", parser: booleanParser{}, token: "true", want: true},
+		{name: "boolean invalid", parser: booleanParser{}, token: "nope", wantErr: true},
+		{name: "auto numeric", parser: autoParser{}, token: "42", want: float64(42)},
+		{name: "auto string", parser: autoParser{}, token: "hi", want: "hi"},
+		{
+			name:   "array of int32",
+			parser: arrayParser{element: int32Parser{}},
+			token:  "1,2,3",
+			want:   []interface{}{int32(1), int32(2), int32(3)},
+		},
+		{
+			name:   "array empty token",
+			parser: arrayParser{element: stringParser{}},
+			token:  "",
+			want:   []interface{}{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.parser.Parse(c.token)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q): expected an error, got nil", c.token)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): unexpected error: %v", c.token, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("Parse(%q) = %#v, want %#v", c.token, got, c.want)
+			}
+		})
+	}
+}
+
+func TestDateParser(t *testing.T) {
+	p := dateParser{layout: "2006-01-02"}
+	got, err := p.Parse("2024-03-05")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, _ := time.Parse("2006-01-02", "2024-03-05")
+	if !got.(time.Time).Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err := p.Parse("not-a-date"); err == nil {
+		t.Fatal("expected an error for an invalid date, got nil")
+	}
+}
+
+func TestParseTypedHeader(t *testing.T) {
+	spec, err := parseTypedHeader("age.int32()", PGStop)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spec.Name != "age" {
+		t.Fatalf("got name %q, want %q", spec.Name, "age")
+	}
+	if _, ok := spec.Parser.(int32Parser); !ok {
+		t.Fatalf("got parser %T, want int32Parser", spec.Parser)
+	}
+
+	if _, err := parseTypedHeader("age", PGStop); err == nil {
+		t.Fatal("expected an error for a header missing a type annotation, got nil")
+	}
+	if _, err := parseTypedHeader("age.bogus()", PGStop); err == nil {
+		t.Fatal("expected an error for an unrecognized column type, got nil")
+	}
+}
+
+func TestTokensToBSONParseGracePolicies(t *testing.T) {
+	columns := []ColumnSpec{
+		{Name: "name", Parser: stringParser{}, ParseGrace: PGStop},
+		{Name: "age", Parser: int32Parser{}, ParseGrace: PGStop},
+	}
+
+	t.Run("PGStop surfaces the parse error", func(t *testing.T) {
+		cols := columns
+		cols[1].ParseGrace = PGStop
+		_, err := tokensToBSON(cols, []string{"alice", "not-a-number"}, 0)
+		if err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+	})
+
+	t.Run("PGAutoCast falls back to the raw token as a string", func(t *testing.T) {
+		cols := []ColumnSpec{
+			{Name: "name", Parser: stringParser{}, ParseGrace: PGStop},
+			{Name: "age", Parser: int32Parser{}, ParseGrace: PGAutoCast},
+		}
+		doc, err := tokensToBSON(cols, []string{"alice", "not-a-number"}, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if doc[1].Value != "not-a-number" {
+			t.Fatalf("got %#v, want the raw token as a string", doc[1].Value)
+		}
+	})
+
+	t.Run("PGSkipField drops only the offending field", func(t *testing.T) {
+		cols := []ColumnSpec{
+			{Name: "name", Parser: stringParser{}, ParseGrace: PGStop},
+			{Name: "age", Parser: int32Parser{}, ParseGrace: PGSkipField},
+		}
+		doc, err := tokensToBSON(cols, []string{"alice", "not-a-number"}, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(doc) != 1 || doc[0].Name != "name" {
+			t.Fatalf("got %#v, want only the name field", doc)
+		}
+	})
+
+	t.Run("PGSkipRow returns errSkipRow", func(t *testing.T) {
+		cols := []ColumnSpec{
+			{Name: "name", Parser: stringParser{}, ParseGrace: PGStop},
+			{Name: "age", Parser: int32Parser{}, ParseGrace: PGSkipRow},
+		}
+		_, err := tokensToBSON(cols, []string{"alice", "not-a-number"}, 0)
+		if err != errSkipRow {
+			t.Fatalf("got error %v, want errSkipRow", err)
+		}
+	})
+}
@@ -0,0 +1,8 @@
+package bson
+
+type D []DocElem
+
+type DocElem struct {
+	Name  string
+	Value interface{}
+}